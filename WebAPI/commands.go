@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PermissionLevel distinguishes users who can only inspect containers
+// from those allowed to restart, stop, or mute them.
+type PermissionLevel string
+
+const (
+	PermissionReadOnly PermissionLevel = "read"
+	PermissionControl  PermissionLevel = "control"
+)
+
+// controlOnlyCommands require PermissionControl; anything else is
+// available to every authorized chat.
+var controlOnlyCommands = map[string]bool{
+	"/restart": true,
+	"/stop":    true,
+	"/mute":    true,
+}
+
+// muteRegistry tracks containers temporarily silenced via /mute, so the
+// log scanners can skip dispatching alerts for them until the mute
+// expires.
+type muteRegistry struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func newMuteRegistry() *muteRegistry {
+	return &muteRegistry{until: make(map[string]time.Time)}
+}
+
+func (m *muteRegistry) isMuted(containerName string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	until, ok := m.until[containerName]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(m.until, containerName)
+		return false
+	}
+	return true
+}
+
+func (m *muteRegistry) mute(containerName string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.until[containerName] = time.Now().Add(d)
+}
+
+// subscriptionRegistry tracks which chats asked, via /subscribe, to
+// additionally receive alerts from containers matching a glob pattern.
+type subscriptionRegistry struct {
+	mu        sync.Mutex
+	byPattern map[string][]string
+}
+
+func newSubscriptionRegistry() *subscriptionRegistry {
+	return &subscriptionRegistry{byPattern: make(map[string][]string)}
+}
+
+func (s *subscriptionRegistry) add(pattern, chatID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.byPattern[pattern] {
+		if existing == chatID {
+			return
+		}
+	}
+	s.byPattern[pattern] = append(s.byPattern[pattern], chatID)
+}
+
+// matchingChatIDs returns every chat ID subscribed to a pattern that
+// matches containerName.
+func (s *subscriptionRegistry) matchingChatIDs(containerName string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var chatIDs []string
+	for pattern, chats := range s.byPattern {
+		if matched, err := path.Match(pattern, containerName); err == nil && matched {
+			chatIDs = append(chatIDs, chats...)
+		}
+	}
+	return chatIDs
+}
+
+// controlState is the mutable state shared between the interactive
+// command handler and the log scanners: which containers are muted, and
+// which chats are subscribed to which container patterns.
+type controlState struct {
+	mutes         *muteRegistry
+	subscriptions *subscriptionRegistry
+}
+
+func newControlState() *controlState {
+	return &controlState{
+		mutes:         newMuteRegistry(),
+		subscriptions: newSubscriptionRegistry(),
+	}
+}
+
+// commandHandler implements the bot's bidirectional control surface: a
+// long-polling getUpdates loop that accepts a fixed allowlist of slash
+// commands from authorized chats, scoped to the Docker API rather than
+// arbitrary shell exec.
+type commandHandler struct {
+	botToken string
+	client   *dockerClient
+	dispatch *dispatcher
+	control  *controlState
+	users    map[string]PermissionLevel
+}
+
+func newCommandHandler(botToken string, client *dockerClient, dispatch *dispatcher, control *controlState, authorizedUsers []AuthorizedUser) *commandHandler {
+	users := make(map[string]PermissionLevel, len(authorizedUsers))
+	for _, u := range authorizedUsers {
+		users[u.ChatID] = u.Permission
+	}
+	return &commandHandler{
+		botToken: botToken,
+		client:   client,
+		dispatch: dispatch,
+		control:  control,
+		users:    users,
+	}
+}
+
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Text string `json:"text"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+type getUpdatesResponse struct {
+	OK     bool             `json:"ok"`
+	Result []telegramUpdate `json:"result"`
+}
+
+func (h *commandHandler) getUpdates(ctx context.Context, offset int64) ([]telegramUpdate, error) {
+	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/getUpdates?timeout=30&offset=%d", h.botToken, offset)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var parsed getUpdatesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, err
+	}
+	if !parsed.OK {
+		return nil, fmt.Errorf("telegram getUpdates returned ok=false")
+	}
+	return parsed.Result, nil
+}
+
+// run long-polls getUpdates and dispatches each incoming message to
+// handleCommand until ctx is cancelled.
+func (h *commandHandler) run(ctx context.Context) {
+	var offset int64
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		updates, err := h.getUpdates(ctx, offset)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error polling Telegram updates: %v\n", err)
+			time.Sleep(2 * time.Second)
+			continue
+		}
+
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message == nil || strings.TrimSpace(u.Message.Text) == "" {
+				continue
+			}
+			h.handleCommand(ctx, u.Message.Chat.ID, u.Message.Text)
+		}
+	}
+}
+
+// handleCommand authorizes, audits, and executes a single incoming
+// message against the fixed command allowlist.
+func (h *commandHandler) handleCommand(ctx context.Context, chatID int64, text string) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return
+	}
+	command, args := fields[0], fields[1:]
+	chatIDStr := strconv.FormatInt(chatID, 10)
+
+	permission, authorized := h.users[chatIDStr]
+	h.audit(chatIDStr, command, args, authorized)
+
+	if !authorized {
+		h.reply(chatID, "⛔ You are not authorized to use this bot.")
+		return
+	}
+	if controlOnlyCommands[command] && permission != PermissionControl {
+		h.reply(chatID, "⛔ This command requires control permission.")
+		return
+	}
+
+	var (
+		reply string
+		err   error
+	)
+	switch command {
+	case "/ps":
+		reply, err = h.cmdPS(ctx)
+	case "/logs":
+		reply, err = h.cmdLogs(ctx, args)
+	case "/restart":
+		reply, err = h.cmdRestart(ctx, args)
+	case "/stop":
+		reply, err = h.cmdStop(ctx, args)
+	case "/mute":
+		reply, err = h.cmdMute(args)
+	case "/subscribe":
+		reply, err = h.cmdSubscribe(chatID, args)
+	default:
+		reply = "Unknown command. Available: /ps /logs /restart /stop /mute /subscribe"
+	}
+	if err != nil {
+		reply = fmt.Sprintf("⚠️ %v", err)
+	}
+	if reply != "" {
+		h.reply(chatID, reply)
+	}
+}
+
+func (h *commandHandler) cmdPS(ctx context.Context) (string, error) {
+	containers, err := h.client.listContainers(ctx, "")
+	if err != nil {
+		return "", err
+	}
+	if len(containers) == 0 {
+		return "No running containers.", nil
+	}
+
+	var b strings.Builder
+	b.WriteString("📦 Running containers:\n")
+	for _, c := range containers {
+		fmt.Fprintf(&b, "• %s\n", c.name())
+	}
+	return b.String(), nil
+}
+
+func (h *commandHandler) cmdLogs(ctx context.Context, args []string) (string, error) {
+	if len(args) < 1 {
+		return "Usage: /logs <name> [n]", nil
+	}
+
+	n := 50
+	if len(args) > 1 {
+		if parsed, err := strconv.Atoi(args[1]); err == nil {
+			n = parsed
+		}
+	}
+
+	container, err := h.client.findByName(ctx, args[0])
+	if err != nil {
+		return "", err
+	}
+	logs, err := h.client.tailLogs(ctx, container.ID, n)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("<pre>%s</pre>", html.EscapeString(logs)), nil
+}
+
+func (h *commandHandler) cmdRestart(ctx context.Context, args []string) (string, error) {
+	if len(args) < 1 {
+		return "Usage: /restart <name>", nil
+	}
+	container, err := h.client.findByName(ctx, args[0])
+	if err != nil {
+		return "", err
+	}
+	if err := h.client.restart(ctx, container.ID); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("🔄 Restarted %s", args[0]), nil
+}
+
+func (h *commandHandler) cmdStop(ctx context.Context, args []string) (string, error) {
+	if len(args) < 1 {
+		return "Usage: /stop <name>", nil
+	}
+	container, err := h.client.findByName(ctx, args[0])
+	if err != nil {
+		return "", err
+	}
+	if err := h.client.stop(ctx, container.ID); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("🛑 Stopped %s", args[0]), nil
+}
+
+func (h *commandHandler) cmdMute(args []string) (string, error) {
+	if len(args) < 2 {
+		return "Usage: /mute <name> <duration>", nil
+	}
+	d, err := time.ParseDuration(args[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid duration %q: %w", args[1], err)
+	}
+	h.control.mutes.mute(args[0], d)
+	return fmt.Sprintf("🔇 Muted %s for %s", args[0], d), nil
+}
+
+func (h *commandHandler) cmdSubscribe(chatID int64, args []string) (string, error) {
+	if len(args) < 1 {
+		return "Usage: /subscribe <pattern>", nil
+	}
+	h.control.subscriptions.add(args[0], strconv.FormatInt(chatID, 10))
+	return fmt.Sprintf("🔔 Subscribed to containers matching %q", args[0]), nil
+}
+
+func (h *commandHandler) reply(chatID int64, text string) {
+	if err := sendToTelegram(text, strconv.FormatInt(chatID, 10)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error replying to chat %d: %v\n", chatID, err)
+	}
+}
+
+// audit records every command invocation, authorized or not, to the
+// "audit" sink if the config defines one, or to stdout otherwise.
+func (h *commandHandler) audit(chatID, command string, args []string, authorized bool) {
+	line := fmt.Sprintf("chat=%s command=%s args=%v authorized=%t", chatID, command, args, authorized)
+
+	if sink, ok := h.dispatch.sinks.get("audit"); ok {
+		event := Event{ContainerName: "logmon", Message: line, RawLine: line, At: time.Now()}
+		if err := sendWithRetry(context.Background(), sink, event); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing audit log: %v\n", err)
+		}
+		return
+	}
+	fmt.Printf("[audit] %s\n", line)
+}
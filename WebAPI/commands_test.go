@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestMuteRegistryExpiry(t *testing.T) {
+	m := newMuteRegistry()
+
+	if m.isMuted("web") {
+		t.Fatal("isMuted(\"web\") = true before any mute, want false")
+	}
+
+	m.mute("web", 10*time.Millisecond)
+	if !m.isMuted("web") {
+		t.Fatal("isMuted(\"web\") = false right after mute, want true")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if m.isMuted("web") {
+		t.Error("isMuted(\"web\") = true after the mute expired, want false")
+	}
+}
+
+func TestSubscriptionRegistryMatchingChatIDsByGlob(t *testing.T) {
+	s := newSubscriptionRegistry()
+	s.add("web-*", "chat1")
+	s.add("worker", "chat2")
+
+	got := s.matchingChatIDs("web-1")
+	if want := []string{"chat1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("matchingChatIDs(\"web-1\") = %v, want %v", got, want)
+	}
+
+	if got := s.matchingChatIDs("worker"); !reflect.DeepEqual(got, []string{"chat2"}) {
+		t.Errorf("matchingChatIDs(\"worker\") = %v, want [chat2]", got)
+	}
+
+	if got := s.matchingChatIDs("db"); len(got) != 0 {
+		t.Errorf("matchingChatIDs(\"db\") = %v, want none", got)
+	}
+}
+
+func TestSubscriptionRegistryAddDeduplicatesSameChat(t *testing.T) {
+	s := newSubscriptionRegistry()
+	s.add("web-*", "chat1")
+	s.add("web-*", "chat1")
+
+	got := s.matchingChatIDs("web-1")
+	if len(got) != 1 {
+		t.Errorf("matchingChatIDs after duplicate add = %v, want exactly one entry", got)
+	}
+}
+
+func TestSubscriptionRegistryMatchingChatIDsAcrossPatterns(t *testing.T) {
+	s := newSubscriptionRegistry()
+	s.add("web-*", "chat1")
+	s.add("*", "chat2")
+
+	got := s.matchingChatIDs("web-1")
+	sort.Strings(got)
+	if want := []string{"chat1", "chat2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("matchingChatIDs(\"web-1\") = %v, want %v", got, want)
+	}
+}
+
+// newTestCommandHandler builds a commandHandler whose Telegram calls fail
+// fast (no TELEGRAM_BOT_TOKEN/TELEGRAM_CHANNEL_ID set), so handleCommand
+// can be exercised without touching the network. Its effects are observed
+// through control state rather than through Telegram replies.
+func newTestCommandHandler(t *testing.T, users []AuthorizedUser) *commandHandler {
+	t.Helper()
+	t.Setenv("TELEGRAM_BOT_TOKEN", "")
+	t.Setenv("TELEGRAM_CHANNEL_ID", "")
+
+	client := newDockerClient()
+	dispatch := newDispatcher(t.TempDir()+"/overflow.jsonl", newSinkRegistry())
+	control := newControlState()
+	return newCommandHandler("", client, dispatch, control, users)
+}
+
+func TestHandleCommandRejectsUnauthorizedChat(t *testing.T) {
+	h := newTestCommandHandler(t, nil)
+
+	h.handleCommand(context.Background(), 1, "/mute web 1m")
+
+	if h.control.mutes.isMuted("web") {
+		t.Error("an unauthorized chat's /mute should have no effect")
+	}
+}
+
+func TestHandleCommandRejectsReadOnlyUserOnControlCommand(t *testing.T) {
+	h := newTestCommandHandler(t, []AuthorizedUser{{ChatID: "1", Permission: PermissionReadOnly}})
+
+	h.handleCommand(context.Background(), 1, "/mute web 1m")
+
+	if h.control.mutes.isMuted("web") {
+		t.Error("a read-only user's /mute should be rejected, but the container was muted")
+	}
+}
+
+func TestHandleCommandAllowsControlUserToMute(t *testing.T) {
+	h := newTestCommandHandler(t, []AuthorizedUser{{ChatID: "1", Permission: PermissionControl}})
+
+	h.handleCommand(context.Background(), 1, "/mute web 1m")
+
+	if !h.control.mutes.isMuted("web") {
+		t.Error("a control user's /mute should take effect")
+	}
+}
+
+func TestHandleCommandAllowsReadOnlyUserToSubscribe(t *testing.T) {
+	h := newTestCommandHandler(t, []AuthorizedUser{{ChatID: "42", Permission: PermissionReadOnly}})
+
+	h.handleCommand(context.Background(), 42, "/subscribe web-*")
+
+	got := h.control.subscriptions.matchingChatIDs("web-1")
+	if want := []string{"42"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("subscriptions after /subscribe = %v, want %v", got, want)
+	}
+}
+
+func TestHandleCommandIgnoresBlankMessage(t *testing.T) {
+	h := newTestCommandHandler(t, []AuthorizedUser{{ChatID: "1", Permission: PermissionControl}})
+	h.handleCommand(context.Background(), 1, "   ")
+}
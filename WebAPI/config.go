@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity is the normalized log level used by the rule engine. Values are
+// ordered from least to most urgent so a min-severity filter can compare
+// with a plain "<".
+type Severity int
+
+const (
+	SeverityDebug Severity = iota
+	SeverityInfo
+	SeverityWarn
+	SeverityError
+	SeverityFatal
+)
+
+func parseSeverity(s string) (Severity, bool) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "DEBUG", "DBG", "TRACE":
+		return SeverityDebug, true
+	case "INFO", "INFORMATION", "INFORMATIONAL":
+		return SeverityInfo, true
+	case "WARN", "WARNING":
+		return SeverityWarn, true
+	case "ERROR", "ERR":
+		return SeverityError, true
+	case "FATAL", "PANIC", "CRITICAL", "CRIT":
+		return SeverityFatal, true
+	default:
+		return SeverityInfo, false
+	}
+}
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityDebug:
+		return "DEBUG"
+	case SeverityInfo:
+		return "INFO"
+	case SeverityWarn:
+		return "WARNING"
+	case SeverityError:
+		return "ERROR"
+	case SeverityFatal:
+		return "FATAL"
+	default:
+		return "INFO"
+	}
+}
+
+// ContainerRule configures how log lines from a matching container are
+// classified: the minimum severity worth alerting on, include/exclude
+// regex filters, and cosmetic overrides for the Telegram message.
+type ContainerRule struct {
+	MinSeverity     string   `json:"min_severity" yaml:"min_severity"`
+	IncludePatterns []string `json:"include_patterns" yaml:"include_patterns"`
+	ExcludePatterns []string `json:"exclude_patterns" yaml:"exclude_patterns"`
+	Emoji           string   `json:"emoji" yaml:"emoji"`
+	Label           string   `json:"label" yaml:"label"`
+	ChatID          string   `json:"chat_id" yaml:"chat_id"`
+	Sinks           []string `json:"sinks" yaml:"sinks"`
+	ContextLines    int      `json:"context_lines" yaml:"context_lines"`
+
+	minSeverity    Severity
+	includeRegexps []*regexp.Regexp
+	excludeRegexps []*regexp.Regexp
+}
+
+// SinkConfig declares one notification destination by name, available to
+// any ContainerRule that lists that name under its own "sinks".
+type SinkConfig struct {
+	Name   string `json:"name" yaml:"name"`
+	Type   string `json:"type" yaml:"type"` // telegram, slack, discord, webhook, unixsocket
+	Target string `json:"target" yaml:"target"`
+}
+
+// AuthorizedUser is one entry in the bot's command allowlist: a Telegram
+// chat ID and the permission level it's granted.
+type AuthorizedUser struct {
+	ChatID     string          `json:"chat_id" yaml:"chat_id"`
+	Permission PermissionLevel `json:"permission" yaml:"permission"`
+}
+
+// Config is the top-level rule file: a set of named sinks, per-container
+// rules that pick which of them to use, and the allowlist for the
+// interactive command handler. Containers are matched by exact name,
+// falling back to a "*" wildcard entry when present.
+type Config struct {
+	Sinks           []SinkConfig              `json:"sinks" yaml:"sinks"`
+	Containers      map[string]*ContainerRule `json:"containers" yaml:"containers"`
+	AuthorizedUsers []AuthorizedUser          `json:"authorized_users" yaml:"authorized_users"`
+}
+
+// defaultContextLines is how many preceding lines are attached to an
+// alert when a rule doesn't set context_lines explicitly.
+const defaultContextLines = 20
+
+// defaultRule is used for containers with neither a dedicated entry nor a
+// wildcard fallback in the config (or when no config was loaded at all).
+var defaultRule = &ContainerRule{
+	minSeverity:  SeverityWarn,
+	Sinks:        []string{defaultSinkName},
+	ContextLines: defaultContextLines,
+}
+
+// loadConfig reads a per-container rule file, choosing a JSON or YAML
+// decoder based on the file extension.
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config %s: %w", path, err)
+	}
+
+	cfg := &Config{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parsing YAML config %s: %w", path, err)
+		}
+	default:
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parsing JSON config %s: %w", path, err)
+		}
+	}
+
+	for name, rule := range cfg.Containers {
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("container rule %q: %w", name, err)
+		}
+	}
+	return cfg, nil
+}
+
+func (r *ContainerRule) compile() error {
+	if severity, ok := parseSeverity(r.MinSeverity); ok {
+		r.minSeverity = severity
+	} else {
+		r.minSeverity = SeverityInfo
+	}
+
+	if len(r.Sinks) == 0 {
+		r.Sinks = []string{defaultSinkName}
+	}
+	if r.ContextLines <= 0 {
+		r.ContextLines = defaultContextLines
+	}
+
+	for _, pattern := range r.IncludePatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid include pattern %q: %w", pattern, err)
+		}
+		r.includeRegexps = append(r.includeRegexps, re)
+	}
+	for _, pattern := range r.ExcludePatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+		r.excludeRegexps = append(r.excludeRegexps, re)
+	}
+	return nil
+}
+
+// matches reports whether a classified log line should trigger an alert
+// under this rule: severity must clear the floor, it must not hit an
+// exclude pattern, and if include patterns are configured at least one
+// must match.
+func (r *ContainerRule) matches(line string, severity Severity) bool {
+	if severity < r.minSeverity {
+		return false
+	}
+	for _, re := range r.excludeRegexps {
+		if re.MatchString(line) {
+			return false
+		}
+	}
+	if len(r.includeRegexps) == 0 {
+		return true
+	}
+	for _, re := range r.includeRegexps {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleFor returns the rule that applies to containerName, falling back to
+// a "*" wildcard entry, or to defaultRule when neither exists or no config
+// was loaded.
+func (c *Config) ruleFor(containerName string) *ContainerRule {
+	if c != nil {
+		if rule, ok := c.Containers[containerName]; ok {
+			return rule
+		}
+		if rule, ok := c.Containers["*"]; ok {
+			return rule
+		}
+	}
+	return defaultRule
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// lineHistory is a fixed-size ring buffer of the most recent raw log
+// lines for one container, used to attach preceding context to an alert.
+type lineHistory struct {
+	mu       sync.Mutex
+	lines    []string
+	capacity int
+}
+
+func newLineHistory(capacity int) *lineHistory {
+	return &lineHistory{capacity: capacity}
+}
+
+func (h *lineHistory) add(line string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lines = append(h.lines, line)
+	if len(h.lines) > h.capacity {
+		h.lines = h.lines[len(h.lines)-h.capacity:]
+	}
+}
+
+func (h *lineHistory) snapshot() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	out := make([]string, len(h.lines))
+	copy(out, h.lines)
+	return out
+}
+
+var (
+	goroutineHeaderPattern = regexp.MustCompile(`^goroutine \d+ \[[^\]]+\]:$`)
+	pythonTracebackHeader  = regexp.MustCompile(`^Traceback \(most recent call last\):$`)
+	javaFramePattern       = regexp.MustCompile(`^\s*at [\w$.]+\([^)]*\)\s*$`)
+	pythonFramePattern     = regexp.MustCompile(`^\s{2,}\S`)
+	goFramePattern         = regexp.MustCompile(`^(\S.*\(.*\)|\t\S.*:\d+.*)$`)
+)
+
+// isContinuation reports whether line extends the block that started
+// with first. Go panics and Python tracebacks are recognized by their
+// header line; Java stack traces have no distinct header, so any line
+// followed by an "at pkg.Class.method(...)" frame is folded in, which
+// lets the exception message merge with its frames one line later.
+func isContinuation(first, line string) bool {
+	switch {
+	case goroutineHeaderPattern.MatchString(first):
+		return goFramePattern.MatchString(line)
+	case pythonTracebackHeader.MatchString(first):
+		return pythonFramePattern.MatchString(line) || strings.Contains(line, ":")
+	default:
+		return javaFramePattern.MatchString(line)
+	}
+}
+
+// stackEvent is one logical event: either a single line, or a multi-line
+// stack trace / traceback that should alert once rather than per frame.
+type stackEvent struct {
+	Lines []string
+}
+
+func (e stackEvent) text() string { return strings.Join(e.Lines, "\n") }
+
+// isTraceHeader reports whether this event is a Go panic's goroutine
+// trace or a Python traceback recognized by its header line. Unlike the
+// triggering "panic: ..." or exception message, these carry no severity
+// keyword of their own, so callers should have them inherit the severity
+// of whatever event preceded them rather than classify them independently.
+func (e stackEvent) isTraceHeader() bool {
+	if len(e.Lines) == 0 {
+		return false
+	}
+	first := e.Lines[0]
+	return goroutineHeaderPattern.MatchString(first) || pythonTracebackHeader.MatchString(first)
+}
+
+// stackAggregator folds Go panics, Python tracebacks, and Java stack
+// traces into one logical event. It holds exactly one pending block at a
+// time, looking one line ahead before deciding the block is complete.
+type stackAggregator struct {
+	buffer []string
+}
+
+func newStackAggregator() *stackAggregator {
+	return &stackAggregator{}
+}
+
+func (a *stackAggregator) pending() bool {
+	return len(a.buffer) > 0
+}
+
+// feed processes one line, returning a completed event whenever the
+// previously pending block turns out not to continue into line.
+func (a *stackAggregator) feed(line string) (stackEvent, bool) {
+	if len(a.buffer) == 0 {
+		a.buffer = []string{line}
+		return stackEvent{}, false
+	}
+	if isContinuation(a.buffer[0], line) {
+		a.buffer = append(a.buffer, line)
+		return stackEvent{}, false
+	}
+
+	event := stackEvent{Lines: a.buffer}
+	a.buffer = []string{line}
+	return event, true
+}
+
+// flush returns whatever is pending (e.g. when the log stream ends) and
+// resets the aggregator.
+func (a *stackAggregator) flush() (stackEvent, bool) {
+	if len(a.buffer) == 0 {
+		return stackEvent{}, false
+	}
+	event := stackEvent{Lines: a.buffer}
+	a.buffer = nil
+	return event, true
+}
+
+// eventAggregator combines stack-trace grouping with ring-buffered
+// context: each completed logical event is paired with the lines that
+// preceded it, not the lines that make it up.
+type eventAggregator struct {
+	history *lineHistory
+	stack   *stackAggregator
+	context []string
+}
+
+func newEventAggregator(contextLines int) *eventAggregator {
+	return &eventAggregator{
+		history: newLineHistory(contextLines),
+		stack:   newStackAggregator(),
+	}
+}
+
+// feed processes one raw line, returning the completed event's text and
+// its preceding context whenever the stack aggregator flushes. isTrace
+// reports whether the event is a goroutine trace or Python traceback with
+// no severity keyword of its own, so the caller can have it inherit the
+// severity of the event it followed instead of classifying it alone.
+func (a *eventAggregator) feed(line string) (text string, context []string, isTrace bool, ok bool) {
+	if !a.stack.pending() {
+		// line starts a new block; its eventual context is whatever
+		// preceded it, snapshotted before line itself is recorded.
+		a.context = a.history.snapshot()
+	}
+
+	event, flushed := a.stack.feed(line)
+
+	var ctx []string
+	if flushed {
+		// event is the block that was pending before line arrived, so
+		// ctx is the snapshot taken when that block started. line has
+		// become the first line of a new block, so re-snapshot now,
+		// before adding it to history, to capture that new block's
+		// preceding context.
+		ctx = a.context
+		a.context = a.history.snapshot()
+	}
+
+	a.history.add(line)
+
+	if !flushed {
+		return "", nil, false, false
+	}
+	return event.text(), ctx, event.isTraceHeader(), true
+}
+
+// flush drains whatever event is still pending, e.g. when the log
+// stream disconnects mid-trace. See feed for isTrace.
+func (a *eventAggregator) flush() (text string, context []string, isTrace bool, ok bool) {
+	event, flushed := a.stack.flush()
+	if !flushed {
+		return "", nil, false, false
+	}
+	return event.text(), a.context, event.isTraceHeader(), true
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEventAggregatorContextExcludesOwnLines(t *testing.T) {
+	agg := newEventAggregator(10)
+
+	type step struct {
+		line        string
+		wantText    string
+		wantContext []string
+		wantOK      bool
+	}
+	steps := []step{
+		{line: "l1 error", wantOK: false},
+		{line: "l2", wantText: "l1 error", wantContext: []string{}, wantOK: true},
+		{line: "l3", wantText: "l2", wantContext: []string{"l1 error"}, wantOK: true},
+		{line: "l4", wantText: "l3", wantContext: []string{"l1 error", "l2"}, wantOK: true},
+	}
+
+	for _, s := range steps {
+		text, context, _, ok := agg.feed(s.line)
+		if ok != s.wantOK {
+			t.Fatalf("feed(%q): ok = %v, want %v", s.line, ok, s.wantOK)
+		}
+		if !ok {
+			continue
+		}
+		if text != s.wantText {
+			t.Errorf("feed(%q): text = %q, want %q", s.line, text, s.wantText)
+		}
+		if !reflect.DeepEqual(context, s.wantContext) {
+			t.Errorf("feed(%q): context = %v, want %v", s.line, context, s.wantContext)
+		}
+		for _, c := range context {
+			if c == text {
+				t.Errorf("feed(%q): context %v includes the event's own line %q", s.line, context, text)
+			}
+		}
+	}
+
+	text, context, _, ok := agg.flush()
+	if !ok {
+		t.Fatal("flush: expected a pending event")
+	}
+	if text != "l4" {
+		t.Errorf("flush: text = %q, want %q", text, "l4")
+	}
+	if want := []string{"l1 error", "l2", "l3"}; !reflect.DeepEqual(context, want) {
+		t.Errorf("flush: context = %v, want %v", context, want)
+	}
+}
+
+func TestEventAggregatorMultiLineStackTrace(t *testing.T) {
+	agg := newEventAggregator(10)
+
+	lines := []string{
+		"before",
+		"goroutine 1 [running]:",
+		"main.main()",
+		"\t/app/main.go:10 +0x1",
+		"after",
+	}
+
+	var gotText string
+	var gotContext []string
+	var gotIsTrace, gotOK bool
+	for _, line := range lines {
+		if text, context, isTrace, ok := agg.feed(line); ok {
+			gotText, gotContext, gotIsTrace, gotOK = text, context, isTrace, ok
+		}
+	}
+
+	if !gotOK {
+		t.Fatal("expected the stack trace block to flush before \"after\"")
+	}
+	wantText := "goroutine 1 [running]:\nmain.main()\n\t/app/main.go:10 +0x1"
+	if gotText != wantText {
+		t.Errorf("text = %q, want %q", gotText, wantText)
+	}
+	if want := []string{"before"}; !reflect.DeepEqual(gotContext, want) {
+		t.Errorf("context = %v, want %v", gotContext, want)
+	}
+	if !gotIsTrace {
+		t.Error("isTrace = false, want true for a goroutine trace block")
+	}
+}
+
+func TestEventAggregatorPlainLineIsNotATrace(t *testing.T) {
+	agg := newEventAggregator(10)
+	agg.feed("first")
+	_, _, isTrace, ok := agg.feed("second")
+	if !ok {
+		t.Fatal("expected \"first\" to flush")
+	}
+	if isTrace {
+		t.Error("isTrace = true for a plain single line, want false")
+	}
+}
+
+func TestLineHistoryCapacity(t *testing.T) {
+	h := newLineHistory(2)
+	h.add("a")
+	h.add("b")
+	h.add("c")
+
+	if got, want := h.snapshot(), []string{"b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("snapshot = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// dispatchItem is one notification in flight between a container's log
+// scanner and its configured sinks, carrying enough to both deduplicate
+// and persist it if it has to spill to disk.
+type dispatchItem struct {
+	ContainerName string    `json:"container"`
+	ChatID        string    `json:"chat_id"`
+	Message       string    `json:"message"`
+	RawLine       string    `json:"raw_line"`
+	SinkNames     []string  `json:"sinks"`
+	At            time.Time `json:"at"`
+}
+
+var (
+	numberPattern  = regexp.MustCompile(`\d+`)
+	uuidPattern    = regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	iso8601Pattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?`)
+)
+
+// fingerprint normalizes the volatile parts of a log line (timestamps,
+// UUIDs, bare numbers) so lines that only differ by a request ID or a
+// counter still hash identically.
+func fingerprint(containerName, line string) string {
+	normalized := iso8601Pattern.ReplaceAllString(line, "<TS>")
+	normalized = uuidPattern.ReplaceAllString(normalized, "<UUID>")
+	normalized = numberPattern.ReplaceAllString(normalized, "<NUM>")
+	return containerName + "|" + normalized
+}
+
+// dedupeEntry tracks how many times a fingerprint has recurred within the
+// current coalescing window.
+type dedupeEntry struct {
+	first       dispatchItem
+	count       int
+	windowStart time.Time
+}
+
+// deduper coalesces repeated log lines into a single "seen N times in
+// last Ms" message instead of forwarding every repeat to the sink.
+type deduper struct {
+	window   time.Duration
+	capacity int
+
+	mu      sync.Mutex
+	entries map[string]*dedupeEntry
+	order   []string
+}
+
+func newDeduper(window time.Duration, capacity int) *deduper {
+	return &deduper{
+		window:   window,
+		capacity: capacity,
+		entries:  make(map[string]*dedupeEntry),
+	}
+}
+
+// observe records an occurrence of fp. The first sighting in a window is
+// forwarded immediately (ok=true); subsequent repeats are only counted,
+// and surface later via flushExpired once their window elapses.
+func (d *deduper) observe(fp string, item dispatchItem) (dispatchItem, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if entry, exists := d.entries[fp]; exists {
+		entry.count++
+		return dispatchItem{}, false
+	}
+
+	d.entries[fp] = &dedupeEntry{first: item, count: 1, windowStart: item.At}
+	d.order = append(d.order, fp)
+	d.evictLocked()
+	return item, true
+}
+
+// flushExpired returns a coalesced summary for every fingerprint whose
+// window has elapsed and that recurred more than once, then resets it.
+func (d *deduper) flushExpired(now time.Time) []dispatchItem {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var flushed []dispatchItem
+	for fp, entry := range d.entries {
+		if now.Sub(entry.windowStart) < d.window {
+			continue
+		}
+		if entry.count > 1 {
+			summary := entry.first
+			summary.Message = fmt.Sprintf("🔁 <b>seen %d times in last %s</b>\n\n%s", entry.count, d.window, summary.Message)
+			summary.At = now
+			flushed = append(flushed, summary)
+		}
+		delete(d.entries, fp)
+	}
+	if len(flushed) > 0 {
+		d.order = d.order[:0]
+		for fp := range d.entries {
+			d.order = append(d.order, fp)
+		}
+	}
+	return flushed
+}
+
+func (d *deduper) evictLocked() {
+	for len(d.order) > d.capacity {
+		oldest := d.order[0]
+		d.order = d.order[1:]
+		delete(d.entries, oldest)
+	}
+}
+
+// tokenBucket is a minimal token-bucket limiter: capacity tokens refilled
+// continuously at refillPerSecond, used to enforce Telegram's global
+// 30 msg/sec and per-chat 20 msg/min ceilings.
+type tokenBucket struct {
+	mu       sync.Mutex
+	tokens   float64
+	capacity float64
+	refill   float64
+	last     time.Time
+}
+
+func newTokenBucket(capacity, refillPerSecond float64, now time.Time) *tokenBucket {
+	return &tokenBucket{tokens: capacity, capacity: capacity, refill: refillPerSecond, last: now}
+}
+
+// reserve consumes a token, returning how long the caller must sleep
+// before it's actually allowed to send.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.refill
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	// The token is borrowed against future refill, so the balance goes
+	// negative and the next caller waits behind this one.
+	deficit := 1 - b.tokens
+	b.tokens--
+	return time.Duration(deficit / b.refill * float64(time.Second))
+}
+
+// diskOverflowQueue is a bounded, file-backed ring buffer: alerts that
+// the dispatcher can't keep up with spill here instead of blocking the
+// log scanner or being dropped outright.
+type diskOverflowQueue struct {
+	path     string
+	capacity int
+	mu       sync.Mutex
+	pending  int // lines appended since the last trim or drain
+}
+
+func newDiskOverflowQueue(path string, capacity int) *diskOverflowQueue {
+	return &diskOverflowQueue{path: path, capacity: capacity}
+}
+
+// push appends item to the backing file in a single write. It never reads
+// the file back: during a burst this is called once per overflowed line
+// on the log scanner's own goroutine, so it must stay O(1) rather than
+// rewriting the whole queue on every call. Capacity is only enforced by
+// an occasional trimLocked, not by push itself.
+func (q *diskOverflowQueue) push(item dispatchItem) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	f, err := os.OpenFile(q.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, writeErr := f.Write(data)
+	closeErr := f.Close()
+	if writeErr != nil {
+		return writeErr
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	q.pending++
+	if q.pending > 2*q.capacity {
+		return q.trimLocked()
+	}
+	return nil
+}
+
+// trimLocked rewrites the file down to the most recent capacity entries.
+// It's the only read-modify-write path, and only runs once more than twice
+// capacity lines have been pushed since the last trim or drain, so an
+// overflow burst still appends in O(1).
+func (q *diskOverflowQueue) trimLocked() error {
+	entries, err := q.readAllLocked()
+	if err != nil {
+		return err
+	}
+	if len(entries) > q.capacity {
+		entries = entries[len(entries)-q.capacity:]
+	}
+	q.pending = 0
+	return q.writeAllLocked(entries)
+}
+
+// drain returns everything queued on disk and truncates the backing
+// file, or (nil, nil) if nothing is queued.
+func (q *diskOverflowQueue) drain() ([]dispatchItem, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries, err := q.readAllLocked()
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	if err := os.Remove(q.path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	q.pending = 0
+	return entries, nil
+}
+
+func (q *diskOverflowQueue) readAllLocked() ([]dispatchItem, error) {
+	f, err := os.Open(q.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []dispatchItem
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var item dispatchItem
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			continue
+		}
+		entries = append(entries, item)
+	}
+	return entries, scanner.Err()
+}
+
+func (q *diskOverflowQueue) writeAllLocked(entries []dispatchItem) error {
+	f, err := os.Create(q.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, item := range entries {
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dispatcher sits between the log scanners and the configured sinks. It
+// deduplicates bursts, fans each alert out to its sinks concurrently, and
+// spills overflow to disk so a burst of thousands of error lines can't
+// overwhelm a rate-limited sink or lose alerts.
+type dispatcher struct {
+	dedup    *deduper
+	overflow *diskOverflowQueue
+	sinks    *sinkRegistry
+
+	in chan dispatchItem
+}
+
+func newDispatcher(overflowPath string, sinks *sinkRegistry) *dispatcher {
+	return &dispatcher{
+		dedup:    newDeduper(30*time.Second, 4096),
+		overflow: newDiskOverflowQueue(overflowPath, 5000),
+		sinks:    sinks,
+		in:       make(chan dispatchItem, 1000),
+	}
+}
+
+// submit deduplicates item and, unless it's a suppressed repeat, queues
+// it for delivery.
+func (d *dispatcher) submit(item dispatchItem) {
+	fp := fingerprint(item.ContainerName, item.RawLine)
+	if coalesced, ok := d.dedup.observe(fp, item); ok {
+		d.enqueue(coalesced)
+	}
+}
+
+// enqueue hands item to the send loop, or to the disk overflow queue if
+// the send loop is backed up.
+func (d *dispatcher) enqueue(item dispatchItem) {
+	select {
+	case d.in <- item:
+	default:
+		if err := d.overflow.push(item); err != nil {
+			fmt.Fprintf(os.Stderr, "Error spilling alert to disk overflow queue: %v\n", err)
+		}
+	}
+}
+
+// run drains the send queue, periodically flushes elapsed dedupe
+// windows, and retries whatever has spilled to disk. It blocks until ctx
+// is cancelled.
+func (d *dispatcher) run(ctx context.Context) {
+	flushTicker := time.NewTicker(time.Second)
+	defer flushTicker.Stop()
+	drainTicker := time.NewTicker(10 * time.Second)
+	defer drainTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case item := <-d.in:
+			d.send(item)
+		case now := <-flushTicker.C:
+			for _, item := range d.dedup.flushExpired(now) {
+				d.enqueue(item)
+			}
+		case <-drainTicker.C:
+			entries, err := d.overflow.drain()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error draining disk overflow queue: %v\n", err)
+				continue
+			}
+			for _, item := range entries {
+				d.enqueue(item)
+			}
+		}
+	}
+}
+
+// send fans item out to every sink it names concurrently, each with its
+// own retry/backoff, so a slow or failing sink can't hold up the others.
+func (d *dispatcher) send(item dispatchItem) {
+	event := Event{
+		ContainerName: item.ContainerName,
+		Message:       item.Message,
+		RawLine:       item.RawLine,
+		ChatID:        item.ChatID,
+		At:            item.At,
+	}
+
+	var wg sync.WaitGroup
+	for _, name := range item.SinkNames {
+		sink, ok := d.sinks.get(name)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Unknown sink %q for container %s\n", name, item.ContainerName)
+			continue
+		}
+		wg.Add(1)
+		go func(sink Sink) {
+			defer wg.Done()
+			if err := sendWithRetry(context.Background(), sink, event); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+			}
+		}(sink)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFingerprintNormalizesVolatileParts(t *testing.T) {
+	a := fingerprint("web", "2024-01-01T10:00:00Z request 42 failed id=8f14e45f-ceea-4c2d-9e8f-9123b45e8a1c")
+	b := fingerprint("web", "2024-01-01T10:00:05Z request 43 failed id=1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d")
+
+	if a != b {
+		t.Errorf("fingerprints should match once timestamps, numbers and UUIDs are normalized:\n%s\n%s", a, b)
+	}
+
+	c := fingerprint("worker", "2024-01-01T10:00:00Z request 42 failed id=8f14e45f-ceea-4c2d-9e8f-9123b45e8a1c")
+	if a == c {
+		t.Errorf("fingerprints for different containers should not match: %s == %s", a, c)
+	}
+}
+
+func TestDiskOverflowQueuePushAppendsWithoutReadingBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "overflow.jsonl")
+	q := newDiskOverflowQueue(path, 5000)
+
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		item := dispatchItem{ContainerName: "web", Message: "boom", At: now}
+		if err := q.push(item); err != nil {
+			t.Fatalf("push %d: %v", i, err)
+		}
+	}
+
+	entries, err := q.readAllLocked()
+	if err != nil {
+		t.Fatalf("readAllLocked: %v", err)
+	}
+	if len(entries) != 10 {
+		t.Fatalf("len(entries) = %d, want 10", len(entries))
+	}
+}
+
+func TestDiskOverflowQueueDrainReturnsAllAndClears(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "overflow.jsonl")
+	q := newDiskOverflowQueue(path, 5000)
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := q.push(dispatchItem{ContainerName: "web", Message: "boom", At: now}); err != nil {
+			t.Fatalf("push: %v", err)
+		}
+	}
+
+	entries, err := q.drain()
+	if err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("len(entries) = %d, want 3", len(entries))
+	}
+
+	again, err := q.drain()
+	if err != nil {
+		t.Fatalf("second drain: %v", err)
+	}
+	if len(again) != 0 {
+		t.Fatalf("second drain should be empty, got %d entries", len(again))
+	}
+}
+
+func TestDiskOverflowQueueTrimsToCapacity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "overflow.jsonl")
+	q := newDiskOverflowQueue(path, 2)
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := q.push(dispatchItem{ContainerName: "web", Message: "boom", At: now}); err != nil {
+			t.Fatalf("push %d: %v", i, err)
+		}
+	}
+
+	entries, err := q.drain()
+	if err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	if len(entries) > 2 {
+		t.Fatalf("len(entries) = %d, want at most capacity 2", len(entries))
+	}
+}
@@ -3,13 +3,19 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
-	"os/exec"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -19,16 +25,19 @@ type TelegramMessage struct {
 	ParseMode string `json:"parse_mode"`
 }
 
-func sendToTelegram(message string) error {
+func sendToTelegram(message, chatIDOverride string) error {
 	botToken := os.Getenv("TELEGRAM_BOT_TOKEN")
 	channelID := os.Getenv("TELEGRAM_CHANNEL_ID")
+	if chatIDOverride != "" {
+		channelID = chatIDOverride
+	}
 
 	if botToken == "" || channelID == "" {
 		return fmt.Errorf("missing environment variables: TELEGRAM_BOT_TOKEN or TELEGRAM_CHANNEL_ID")
 	}
 
 	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", botToken)
-	
+
 	telegramMsg := TelegramMessage{
 		ChatID:    channelID,
 		Text:      message,
@@ -48,73 +57,734 @@ func sendToTelegram(message string) error {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return &telegramAPIError{
+				StatusCode: resp.StatusCode,
+				RetryAfter: parseRetryAfter(body),
+				Message:    fmt.Sprintf("telegram API error: %s", string(body)),
+			}
+		}
 		return fmt.Errorf("telegram API error: %s", string(body))
 	}
 
 	return nil
 }
 
-func formatLogMessage(containerName, logLine string) string {
+// telegramAPIError carries Telegram's own retry_after hint from a 429
+// response so callers can back off by exactly as long as Telegram asked.
+type telegramAPIError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e *telegramAPIError) Error() string {
+	return e.Message
+}
+
+func parseRetryAfter(body []byte) time.Duration {
+	var payload struct {
+		Parameters struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return 0
+	}
+	return time.Duration(payload.Parameters.RetryAfter) * time.Second
+}
+
+func formatLogMessage(containerName, logLine string, severity Severity, rule *ContainerRule) string {
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	
-	// Determine if it's an error or warning
-	var emoji, level string
-	if strings.Contains(strings.ToLower(logLine), "error") {
-		emoji = "❌"
-		level = "ERROR"
-	} else {
-		emoji = "⚠️"
-		level = "WARNING"
+
+	emoji := severityEmoji(severity)
+	label := severity.String()
+	if rule.Emoji != "" {
+		emoji = rule.Emoji
+	}
+	if rule.Label != "" {
+		label = rule.Label
 	}
 
 	return fmt.Sprintf("%s <b>%s</b>\n"+
 		"🕒 %s\n"+
 		"📦 Container: %s\n"+
 		"\n%s",
-		emoji, level, timestamp, containerName, logLine)
+		emoji, label, timestamp, containerName, logLine)
+}
+
+// telegramMessageLimit is Telegram's hard cap on a sendMessage text body;
+// alerts that exceed it (typically a stack trace plus its context) are
+// delivered via sendDocument instead.
+const telegramMessageLimit = 4096
+
+// buildContextualMessage composes the alert sent to a sink: the
+// preceding ring-buffered lines as a fixed-width context block, followed
+// by the formatted event itself.
+func buildContextualMessage(containerName, eventText string, severity Severity, rule *ContainerRule, context []string) string {
+	message := formatLogMessage(containerName, eventText, severity, rule)
+	if len(context) == 0 {
+		return message
+	}
+	return fmt.Sprintf("📜 <b>Preceding context</b>\n<pre>%s</pre>\n\n%s",
+		html.EscapeString(strings.Join(context, "\n")), message)
 }
 
-func monitorContainerLogs(containerName string) {
-	cmd := exec.Command("docker", "logs", "-f", containerName)
-	stdout, err := cmd.StdoutPipe()
+// sendTelegramDocument uploads content as a text file attachment,
+// used when a formatted alert exceeds telegramMessageLimit.
+func sendTelegramDocument(filename, content, chatIDOverride string) error {
+	botToken := os.Getenv("TELEGRAM_BOT_TOKEN")
+	channelID := os.Getenv("TELEGRAM_CHANNEL_ID")
+	if chatIDOverride != "" {
+		channelID = chatIDOverride
+	}
+	if botToken == "" || channelID == "" {
+		return fmt.Errorf("missing environment variables: TELEGRAM_BOT_TOKEN or TELEGRAM_CHANNEL_ID")
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if err := writer.WriteField("chat_id", channelID); err != nil {
+		return err
+	}
+	part, err := writer.CreateFormFile("document", filename)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating stdout pipe: %v\n", err)
-		return
+		return err
+	}
+	if _, err := part.Write([]byte(content)); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
 	}
 
-	if err := cmd.Start(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error starting docker logs: %v\n", err)
-		return
+	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendDocument", botToken)
+	resp, err := http.Post(apiURL, writer.FormDataContentType(), &body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return &telegramAPIError{
+				StatusCode: resp.StatusCode,
+				RetryAfter: parseRetryAfter(respBody),
+				Message:    fmt.Sprintf("telegram API error: %s", string(respBody)),
+			}
+		}
+		return fmt.Errorf("telegram API error: %s", string(respBody))
+	}
+	return nil
+}
+
+func severityEmoji(s Severity) string {
+	switch s {
+	case SeverityFatal:
+		return "💀"
+	case SeverityError:
+		return "❌"
+	case SeverityWarn:
+		return "⚠️"
+	default:
+		return "ℹ️"
+	}
+}
+
+// dockerClient talks to the Docker Engine API over its unix socket (or
+// DOCKER_HOST, if set to a tcp:// address), the same transport the docker
+// CLI itself uses instead of shelling out to the docker binary.
+type dockerClient struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+func newDockerClient() *dockerClient {
+	host := os.Getenv("DOCKER_HOST")
+
+	transport := &http.Transport{}
+	baseURL := "http://docker"
+
+	switch {
+	case strings.HasPrefix(host, "tcp://"):
+		baseURL = "http://" + strings.TrimPrefix(host, "tcp://")
+	default:
+		socketPath := "/var/run/docker.sock"
+		if strings.HasPrefix(host, "unix://") {
+			socketPath = strings.TrimPrefix(host, "unix://")
+		}
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		}
+	}
+
+	return &dockerClient{
+		httpClient: &http.Client{Transport: transport},
+		baseURL:    baseURL,
+	}
+}
+
+func (c *dockerClient) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
 	}
+	return c.httpClient.Do(req)
+}
 
-	scanner := bufio.NewScanner(stdout)
+func (c *dockerClient) post(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.httpClient.Do(req)
+}
+
+// findByName resolves a running container's name to its current record,
+// for commands that take a name rather than an ID.
+func (c *dockerClient) findByName(ctx context.Context, name string) (*dockerContainer, error) {
+	containers, err := c.listContainers(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	for i := range containers {
+		if containers[i].name() == name {
+			return &containers[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no running container named %q", name)
+}
+
+func (c *dockerClient) restart(ctx context.Context, id string) error {
+	resp, err := c.post(ctx, fmt.Sprintf("/containers/%s/restart", id))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("docker API error restarting %s: %s", id, string(body))
+	}
+	return nil
+}
+
+func (c *dockerClient) stop(ctx context.Context, id string) error {
+	resp, err := c.post(ctx, fmt.Sprintf("/containers/%s/stop", id))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotModified {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("docker API error stopping %s: %s", id, string(body))
+	}
+	return nil
+}
+
+// tailLogs fetches the last n lines of a container's logs without
+// following, for the /logs command.
+func (c *dockerClient) tailLogs(ctx context.Context, id string, n int) (string, error) {
+	tty, err := c.inspectTty(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	path := fmt.Sprintf("/containers/%s/logs?stdout=true&stderr=true&tail=%d", id, n)
+	resp, err := c.get(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("docker API error reading logs for %s: %s", id, string(body))
+	}
+
+	var reader io.Reader = resp.Body
+	if !tty {
+		reader = newStdDemuxReader(resp.Body)
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, reader); err != nil && err != io.EOF {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// inspectTty reports whether containerID was started with a TTY attached
+// (docker run -t / compose's tty: true), by way of a single /containers/
+// {id}/json call. Docker only multiplexes stdout/stderr behind the 8-byte
+// frame header stdDemuxReader strips when there's no TTY; a TTY container
+// streams raw bytes, and running them through the demuxer anyway corrupts
+// the frame length field with arbitrary log bytes.
+func (c *dockerClient) inspectTty(ctx context.Context, containerID string) (bool, error) {
+	resp, err := c.get(ctx, fmt.Sprintf("/containers/%s/json", containerID))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return false, fmt.Errorf("docker API error inspecting %s: %s", containerID, string(body))
+	}
+
+	var inspect struct {
+		Config struct {
+			Tty bool `json:"Tty"`
+		} `json:"Config"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&inspect); err != nil {
+		return false, err
+	}
+	return inspect.Config.Tty, nil
+}
+
+// dockerContainer is the subset of the /containers/json response we need.
+type dockerContainer struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Labels map[string]string `json:"Labels"`
+}
+
+func (c *dockerContainer) name() string {
+	if len(c.Names) == 0 {
+		return c.ID
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// listContainers returns the currently running containers, optionally
+// restricted to those carrying labelFilter (e.g. "logmon.enable=true").
+func (c *dockerClient) listContainers(ctx context.Context, labelFilter string) ([]dockerContainer, error) {
+	path := "/containers/json"
+	if labelFilter != "" {
+		filters, err := json.Marshal(map[string][]string{"label": {labelFilter}})
+		if err != nil {
+			return nil, err
+		}
+		path += "?filters=" + url.QueryEscape(string(filters))
+	}
+
+	resp, err := c.get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("docker API error listing containers: %s", string(body))
+	}
+
+	var containers []dockerContainer
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, err
+	}
+	return containers, nil
+}
+
+// dockerEvent is the subset of the /events stream we act on.
+type dockerEvent struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+}
+
+// streamEvents subscribes to the Docker /events endpoint and emits every
+// container event (start, die, and all other actions) on the returned
+// channel until ctx is cancelled or the daemon connection drops.
+func (c *dockerClient) streamEvents(ctx context.Context) (<-chan dockerEvent, error) {
+	filters, err := json.Marshal(map[string][]string{"type": {"container"}})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.get(ctx, "/events?filters="+url.QueryEscape(string(filters)))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("docker API error streaming events: %s", string(body))
+	}
+
+	events := make(chan dockerEvent)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+
+		decoder := json.NewDecoder(resp.Body)
+		for {
+			var evt dockerEvent
+			if err := decoder.Decode(&evt); err != nil {
+				return
+			}
+			select {
+			case events <- evt:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// followLogs streams stdout/stderr for containerID via the Docker API's
+// log endpoint, calling onLine for every log line with the per-line
+// timestamp Docker attaches (zero if a line can't be parsed). If since is
+// non-zero it is passed as the `since=` query parameter, with nanosecond
+// precision, so reconnects resume from the last line actually read
+// instead of replaying everything since the connection was opened. tty
+// must reflect whether the container was started with a TTY attached
+// (see inspectTty): Docker only multiplexes the stream behind stdDemuxReader's
+// frame header when there's no TTY, and running a TTY stream through it
+// corrupts on arbitrary log bytes.
+func (c *dockerClient) followLogs(ctx context.Context, containerID string, since time.Time, tty bool, onLine func(ts time.Time, line string)) error {
+	path := fmt.Sprintf("/containers/%s/logs?follow=true&stdout=true&stderr=true&timestamps=true", containerID)
+	if !since.IsZero() {
+		path += "&since=" + strconv.FormatInt(since.Unix(), 10) + "." + fmt.Sprintf("%09d", since.Nanosecond())
+	}
+
+	resp, err := c.get(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("docker API error reading logs for %s: %s", containerID, string(body))
+	}
+
+	var reader io.Reader = resp.Body
+	if !tty {
+		// Docker multiplexes stdout/stderr frames behind an 8-byte header
+		// when the container wasn't started with a TTY; stdDemuxReader
+		// strips it.
+		reader = newStdDemuxReader(resp.Body)
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
 	for scanner.Scan() {
-		line := scanner.Text()
-		lowerLine := strings.ToLower(line)
-
-		// Check if the line contains error or warning
-		if strings.Contains(lowerLine, "error") || strings.Contains(lowerLine, "warning") {
-			message := formatLogMessage(containerName, line)
-			if err := sendToTelegram(message); err != nil {
-				fmt.Fprintf(os.Stderr, "Error sending to Telegram: %v\n", err)
+		ts, line := splitDockerTimestamp(scanner.Text())
+		onLine(ts, line)
+	}
+	return scanner.Err()
+}
+
+// splitDockerTimestamp strips the RFC3339Nano timestamp Docker prefixes to
+// each line when timestamps=true, returning it alongside the remaining log
+// text. If the prefix doesn't parse (unexpected format, truncated line),
+// ts is the zero value and line is returned unmodified.
+func splitDockerTimestamp(raw string) (ts time.Time, line string) {
+	prefix, rest, found := strings.Cut(raw, " ")
+	if !found {
+		return time.Time{}, raw
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, prefix)
+	if err != nil {
+		return time.Time{}, raw
+	}
+	return parsed, rest
+}
+
+// stdDemuxReader unwraps the Docker log stream framing ([stream][0 0 0][size])
+// so bufio.Scanner sees plain log lines regardless of whether the frames
+// interleave stdout and stderr.
+type stdDemuxReader struct {
+	src     io.Reader
+	pending []byte
+}
+
+func newStdDemuxReader(src io.Reader) *stdDemuxReader {
+	return &stdDemuxReader{src: src}
+}
+
+func (r *stdDemuxReader) Read(p []byte) (int, error) {
+	if len(r.pending) > 0 {
+		n := copy(p, r.pending)
+		r.pending = r.pending[n:]
+		return n, nil
+	}
+
+	var header [8]byte
+	if _, err := io.ReadFull(r.src, header[:]); err != nil {
+		return 0, err
+	}
+	size := int(header[4])<<24 | int(header[5])<<16 | int(header[6])<<8 | int(header[7])
+	if size == 0 {
+		return 0, nil
+	}
+
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(r.src, frame); err != nil {
+		return 0, err
+	}
+
+	n := copy(p, frame)
+	if n < len(frame) {
+		r.pending = frame[n:]
+	}
+	return n, nil
+}
+
+func monitorContainerLogs(ctx context.Context, client *dockerClient, container dockerContainer, cfg *Config, dispatch *dispatcher, control *controlState) {
+	name := container.name()
+	rule := cfg.ruleFor(name)
+	fmt.Printf("Monitoring logs for container: %s\n", name)
+
+	agg := newEventAggregator(rule.ContextLines)
+	lastSeverity := SeverityInfo
+	emit := func(text string, context []string, isTrace bool) {
+		if control.mutes.isMuted(name) {
+			return
+		}
+		severity, _ := classifyLogLine(text)
+		if isTrace && lastSeverity > severity {
+			// A goroutine trace or Python traceback carries no severity
+			// keyword of its own; it should alert at the level of the
+			// panic/exception message that triggered it, not get judged
+			// (and likely dropped) on its own.
+			severity = lastSeverity
+		}
+		if strings.TrimSpace(text) != "" {
+			lastSeverity = severity
+		}
+		if !rule.matches(text, severity) {
+			return
+		}
+
+		message := buildContextualMessage(name, text, severity, rule, context)
+		dispatch.submit(dispatchItem{
+			ContainerName: name,
+			ChatID:        rule.ChatID,
+			Message:       message,
+			RawLine:       text,
+			SinkNames:     rule.Sinks,
+			At:            time.Now(),
+		})
+		for _, chatID := range control.subscriptions.matchingChatIDs(name) {
+			dispatch.submit(dispatchItem{
+				ContainerName: name,
+				ChatID:        chatID,
+				Message:       message,
+				RawLine:       text,
+				SinkNames:     []string{defaultSinkName},
+				At:            time.Now(),
+			})
+		}
+	}
+
+	tty, err := client.inspectTty(ctx, container.ID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error inspecting container %s, assuming no TTY: %v\n", name, err)
+	}
+
+	var since time.Time
+	for {
+		var lastSeen time.Time
+		err := client.followLogs(ctx, container.ID, since, tty, func(ts time.Time, line string) {
+			if !ts.IsZero() {
+				lastSeen = ts
 			}
+			if text, context, isTrace, ok := agg.feed(line); ok {
+				emit(text, context, isTrace)
+			}
+		})
+		if text, context, isTrace, ok := agg.flush(); ok {
+			emit(text, context, isTrace)
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Log stream for %s dropped: %v (reconnecting)\n", name, err)
 		}
+
+		// Resume from the last line we actually read, not from when this
+		// connection attempt started, so a stream that drops after hours
+		// doesn't replay its entire backlog (and flood every alert in it
+		// straight past the dedupe window, which will long since have
+		// expired by then).
+		if !lastSeen.IsZero() {
+			since = lastSeen.Add(time.Nanosecond)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// containerWatcher tracks one log-follow goroutine per running container,
+// spawning them on "start" events and tearing them down on "die" events so
+// a single process watches every container on the host.
+type containerWatcher struct {
+	client      *dockerClient
+	labelFilter string
+	cfg         *Config
+	dispatch    *dispatcher
+	control     *controlState
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func newContainerWatcher(client *dockerClient, labelFilter string, cfg *Config, dispatch *dispatcher, control *controlState) *containerWatcher {
+	return &containerWatcher{
+		client:      client,
+		labelFilter: labelFilter,
+		cfg:         cfg,
+		dispatch:    dispatch,
+		control:     control,
+		cancels:     make(map[string]context.CancelFunc),
 	}
+}
 
-	if err := scanner.Err(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading logs: %v\n", err)
+func (w *containerWatcher) watch(ctx context.Context, container dockerContainer) {
+	w.mu.Lock()
+	if _, exists := w.cancels[container.ID]; exists {
+		w.mu.Unlock()
+		return
 	}
+	watchCtx, cancel := context.WithCancel(ctx)
+	w.cancels[container.ID] = cancel
+	w.mu.Unlock()
+
+	go monitorContainerLogs(watchCtx, w.client, container, w.cfg, w.dispatch, w.control)
+}
 
-	cmd.Wait()
+func (w *containerWatcher) unwatch(containerID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if cancel, exists := w.cancels[containerID]; exists {
+		cancel()
+		delete(w.cancels, containerID)
+	}
+}
+
+func (w *containerWatcher) matchesFilter(labels map[string]string) bool {
+	if w.labelFilter == "" {
+		return true
+	}
+	parts := strings.SplitN(w.labelFilter, "=", 2)
+	if len(parts) != 2 {
+		return labels[w.labelFilter] != ""
+	}
+	return labels[parts[0]] == parts[1]
+}
+
+// run discovers the containers already running at startup, then follows
+// the Docker event stream to pick up containers as they start and stop,
+// reconnecting automatically if the daemon goes away.
+func (w *containerWatcher) run(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		containers, err := w.client.listContainers(ctx, w.labelFilter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing containers: %v (retrying)\n", err)
+			time.Sleep(2 * time.Second)
+			continue
+		}
+		for _, container := range containers {
+			w.watch(ctx, container)
+		}
+
+		events, err := w.client.streamEvents(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error subscribing to Docker events: %v (reconnecting)\n", err)
+			time.Sleep(2 * time.Second)
+			continue
+		}
+
+		for evt := range events {
+			switch evt.Action {
+			case "start":
+				if !w.matchesFilter(evt.Actor.Attributes) {
+					continue
+				}
+				w.watch(ctx, dockerContainer{
+					ID:     evt.Actor.ID,
+					Names:  []string{evt.Actor.Attributes["name"]},
+					Labels: evt.Actor.Attributes,
+				})
+			case "die":
+				w.unwatch(evt.Actor.ID)
+			}
+		}
+
+		// The events channel only closes when the connection to the
+		// daemon drops; loop back around to rediscover containers and
+		// resubscribe once it's back.
+		fmt.Fprintln(os.Stderr, "Docker event stream closed, reconnecting...")
+		time.Sleep(2 * time.Second)
+	}
 }
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: docker_log_monitor <container_name>")
+	labelFilter := os.Getenv("LOGMON_LABEL_FILTER")
+
+	var cfg *Config
+	if configPath := os.Getenv("LOGMON_CONFIG_FILE"); configPath != "" {
+		loaded, err := loadConfig(configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config %s: %v\n", configPath, err)
+			os.Exit(1)
+		}
+		cfg = loaded
+	}
+
+	overflowPath := os.Getenv("LOGMON_OVERFLOW_FILE")
+	if overflowPath == "" {
+		overflowPath = "logmon_overflow.jsonl"
+	}
+
+	sinks, err := buildSinkRegistry(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error building sinks: %v\n", err)
 		os.Exit(1)
 	}
 
-	containerName := os.Args[1]
-	fmt.Printf("Monitoring logs for container: %s\n", containerName)
-	monitorContainerLogs(containerName)
-} 
\ No newline at end of file
+	ctx := context.Background()
+	dispatch := newDispatcher(overflowPath, sinks)
+	go dispatch.run(ctx)
+
+	client := newDockerClient()
+	control := newControlState()
+	watcher := newContainerWatcher(client, labelFilter, cfg, dispatch, control)
+
+	if botToken := os.Getenv("TELEGRAM_BOT_TOKEN"); botToken != "" && cfg != nil && len(cfg.AuthorizedUsers) > 0 {
+		commands := newCommandHandler(botToken, client, dispatch, control, cfg.AuthorizedUsers)
+		go commands.run(ctx)
+		fmt.Println("Interactive command handler enabled")
+	}
+
+	if socketPath := os.Getenv("LOGMON_SOCKET_LISTEN"); socketPath != "" {
+		listener := newUnixSocketListener(socketPath, dispatch)
+		go func() {
+			if err := listener.run(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "Unix socket listener error: %v\n", err)
+			}
+		}()
+		fmt.Printf("Listening for external alerts on unix socket %s\n", socketPath)
+	}
+
+	fmt.Println("Starting Docker log monitor (event-driven, multi-container)")
+	if labelFilter != "" {
+		fmt.Printf("Filtering containers by label: %s\n", labelFilter)
+	}
+
+	watcher.run(ctx)
+}
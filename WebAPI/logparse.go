@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// structuredLogEntry covers the common field names used by structured
+// loggers emitting one JSON object per line: logrus and zap both default
+// to "level"/"msg"/"ts", while others favor "severity"/"message"/"time".
+type structuredLogEntry struct {
+	Level     string `json:"level"`
+	Severity  string `json:"severity"`
+	Msg       string `json:"msg"`
+	Message   string `json:"message"`
+	Timestamp string `json:"ts"`
+	Time      string `json:"time"`
+	Error     string `json:"error"`
+}
+
+func (e structuredLogEntry) level() string {
+	if e.Level != "" {
+		return e.Level
+	}
+	return e.Severity
+}
+
+func (e structuredLogEntry) message() string {
+	switch {
+	case e.Msg != "":
+		return e.Msg
+	case e.Message != "":
+		return e.Message
+	case e.Error != "":
+		return e.Error
+	default:
+		return ""
+	}
+}
+
+var (
+	// logrus's default text formatter: "time=... level=error msg=\"boom\" ..."
+	logrusLinePattern = regexp.MustCompile(`(?:^|\s)level=(\w+)\s.*?msg="((?:[^"\\]|\\.)*)"`)
+
+	// Python logging.basicConfig()'s default format:
+	// "2024-01-01 10:00:00,000 - name - ERROR - message"
+	pythonLoggingPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}[,.]\d+\s*-\s*\S+\s*-\s*(DEBUG|INFO|WARNING|ERROR|CRITICAL)\s*-\s*(.*)$`)
+
+	// The lighter "LEVEL:logger:message" format logging.basicConfig() also
+	// produces when no explicit format string is set.
+	pythonBasicConfigPattern = regexp.MustCompile(`^(DEBUG|INFO|WARNING|ERROR|CRITICAL):\S+:(.*)$`)
+
+	// Guards against firing on negations like "no errors found" or
+	// "0 warnings" when falling back to naive keyword matching.
+	negatedKeywordPattern = regexp.MustCompile(`\b(?:no|0|zero)\s+(?:\w+\s+)?(?:error|warn)`)
+)
+
+// classifyLogLine extracts a normalized severity and the underlying
+// message from a raw log line. It tries structured JSON first, then known
+// text formats (logrus, Python logging), and only falls back to naive
+// keyword matching when no level field can be found.
+func classifyLogLine(line string) (Severity, string) {
+	trimmed := strings.TrimSpace(line)
+
+	if strings.HasPrefix(trimmed, "{") {
+		var entry structuredLogEntry
+		if err := json.Unmarshal([]byte(trimmed), &entry); err == nil {
+			if severity, ok := parseSeverity(entry.level()); ok {
+				return severity, firstNonEmpty(entry.message(), trimmed)
+			}
+		}
+	}
+
+	if m := logrusLinePattern.FindStringSubmatch(line); m != nil {
+		if severity, ok := parseSeverity(m[1]); ok {
+			return severity, unescapeLogrusMsg(m[2])
+		}
+	}
+
+	if m := pythonLoggingPattern.FindStringSubmatch(line); m != nil {
+		severity, _ := parseSeverity(m[1])
+		return severity, m[2]
+	}
+	if m := pythonBasicConfigPattern.FindStringSubmatch(line); m != nil {
+		severity, _ := parseSeverity(m[1])
+		return severity, m[2]
+	}
+
+	return classifyByKeyword(line), line
+}
+
+// classifyByKeyword is the last resort for lines with no recognizable
+// level field: a keyword match that's careful not to fire on negations
+// such as "no errors found".
+func classifyByKeyword(line string) Severity {
+	lower := strings.ToLower(line)
+	if negatedKeywordPattern.MatchString(lower) {
+		return SeverityInfo
+	}
+	switch {
+	case strings.Contains(lower, "fatal") || strings.Contains(lower, "panic"):
+		return SeverityFatal
+	case strings.Contains(lower, "error"):
+		return SeverityError
+	case strings.Contains(lower, "warn"):
+		return SeverityWarn
+	default:
+		return SeverityInfo
+	}
+}
+
+func unescapeLogrusMsg(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, `\"`, `"`), `\\`, `\`)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func TestClassifyLogLine(t *testing.T) {
+	tests := []struct {
+		name        string
+		line        string
+		wantSev     Severity
+		wantMessage string
+	}{
+		{
+			name:        "structured JSON logrus-style",
+			line:        `{"level":"error","msg":"connection refused"}`,
+			wantSev:     SeverityError,
+			wantMessage: "connection refused",
+		},
+		{
+			name:        "logrus text formatter",
+			line:        `time="2024-01-01T10:00:00Z" level=warning msg="disk almost full"`,
+			wantSev:     SeverityWarn,
+			wantMessage: "disk almost full",
+		},
+		{
+			name:        "python logging default format",
+			line:        "2024-01-01 10:00:00,123 - myapp - ERROR - boom",
+			wantSev:     SeverityError,
+			wantMessage: "boom",
+		},
+		{
+			name:        "python basicConfig format",
+			line:        "CRITICAL:myapp:out of memory",
+			wantSev:     SeverityFatal,
+			wantMessage: "out of memory",
+		},
+		{
+			name:        "naive keyword fallback",
+			line:        "panic: runtime error: index out of range",
+			wantSev:     SeverityFatal,
+			wantMessage: "panic: runtime error: index out of range",
+		},
+		{
+			name:        "negated keyword is not an error",
+			line:        "completed with 0 errors",
+			wantSev:     SeverityInfo,
+			wantMessage: "completed with 0 errors",
+		},
+		{
+			name:        "plain line with no markers",
+			line:        "server listening on :8080",
+			wantSev:     SeverityInfo,
+			wantMessage: "server listening on :8080",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotSev, gotMsg := classifyLogLine(tt.line)
+			if gotSev != tt.wantSev {
+				t.Errorf("severity = %v, want %v", gotSev, tt.wantSev)
+			}
+			if gotMsg != tt.wantMessage {
+				t.Errorf("message = %q, want %q", gotMsg, tt.wantMessage)
+			}
+		})
+	}
+}
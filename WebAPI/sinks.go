@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// defaultSinkName is the sink every container rule uses when it doesn't
+// name any of its own, preserving this tool's original Telegram-only
+// behavior.
+const defaultSinkName = "telegram"
+
+// Event is what a Sink actually delivers: a fully formatted alert plus
+// enough raw context for sinks that want to re-render it (a webhook
+// forwarding structured JSON, say, instead of the HTML-formatted text).
+type Event struct {
+	ContainerName string    `json:"container"`
+	Message       string    `json:"message"`
+	RawLine       string    `json:"raw_line"`
+	ChatID        string    `json:"chat_id,omitempty"`
+	At            time.Time `json:"at"`
+}
+
+// Sink delivers an Event to one notification channel. Implementations
+// should return a *telegramAPIError (or wrap one) when the failure
+// carries a retry-after hint, so sendWithRetry can honor it.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, event Event) error
+}
+
+// sinkRegistry looks sinks up by the name a ContainerRule references.
+type sinkRegistry struct {
+	mu    sync.RWMutex
+	sinks map[string]Sink
+}
+
+func newSinkRegistry() *sinkRegistry {
+	return &sinkRegistry{sinks: make(map[string]Sink)}
+}
+
+func (r *sinkRegistry) register(sink Sink) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sinks[sink.Name()] = sink
+}
+
+func (r *sinkRegistry) get(name string) (Sink, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sink, ok := r.sinks[name]
+	return sink, ok
+}
+
+// buildSinkRegistry constructs every sink declared in cfg.Sinks, then
+// registers a default env-configured Telegram sink unless the config
+// already defines one under that name.
+func buildSinkRegistry(cfg *Config) (*sinkRegistry, error) {
+	registry := newSinkRegistry()
+
+	if cfg != nil {
+		for _, sc := range cfg.Sinks {
+			sink, err := newSink(sc)
+			if err != nil {
+				return nil, fmt.Errorf("sink %q: %w", sc.Name, err)
+			}
+			registry.register(sink)
+		}
+	}
+
+	if _, ok := registry.get(defaultSinkName); !ok {
+		registry.register(newTelegramSink(defaultSinkName, ""))
+	}
+
+	return registry, nil
+}
+
+func newSink(sc SinkConfig) (Sink, error) {
+	name := sc.Name
+	if name == "" {
+		name = sc.Type
+	}
+
+	switch sc.Type {
+	case "telegram":
+		return newTelegramSink(name, sc.Target), nil
+	case "slack":
+		return &slackSink{name: name, webhookURL: sc.Target}, nil
+	case "discord":
+		return &discordSink{name: name, webhookURL: sc.Target}, nil
+	case "webhook":
+		return &webhookSink{name: name, url: sc.Target}, nil
+	case "unixsocket":
+		return &unixSocketSink{name: name, path: sc.Target}, nil
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", sc.Type)
+	}
+}
+
+// telegramSink is the original behavior, now behind the Sink interface.
+// It owns the rate limiters from the dedup/rate-limit redesign: a global
+// bucket shared across every chat this sink posts to, plus one per-chat
+// bucket, since both ceilings are properties of the Telegram Bot API
+// itself rather than of any particular alert.
+type telegramSink struct {
+	name           string
+	chatIDOverride string
+
+	global *tokenBucket
+
+	perChatMu sync.Mutex
+	perChat   map[string]*tokenBucket
+}
+
+func newTelegramSink(name, chatIDOverride string) *telegramSink {
+	return &telegramSink{
+		name:           name,
+		chatIDOverride: chatIDOverride,
+		global:         newTokenBucket(30, 30, time.Now()),
+		perChat:        make(map[string]*tokenBucket),
+	}
+}
+
+func (s *telegramSink) Name() string { return s.name }
+
+func (s *telegramSink) chatBucket(chatID string) *tokenBucket {
+	s.perChatMu.Lock()
+	defer s.perChatMu.Unlock()
+
+	bucket, ok := s.perChat[chatID]
+	if !ok {
+		bucket = newTokenBucket(20, 20.0/60.0, time.Now())
+		s.perChat[chatID] = bucket
+	}
+	return bucket
+}
+
+func (s *telegramSink) Send(ctx context.Context, event Event) error {
+	chatID := resolveChatID(s.chatIDOverride)
+	if event.ChatID != "" {
+		chatID = event.ChatID
+	}
+
+	if wait := s.global.reserve(time.Now()); wait > 0 {
+		time.Sleep(wait)
+	}
+	if wait := s.chatBucket(chatID).reserve(time.Now()); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	if len(event.Message) > telegramMessageLimit {
+		filename := fmt.Sprintf("%s-alert.txt", event.ContainerName)
+		return sendTelegramDocument(filename, stripHTML(event.Message), chatID)
+	}
+	return sendToTelegram(event.Message, chatID)
+}
+
+// resolveChatID applies a rule's chat_id override, falling back to the
+// globally configured TELEGRAM_CHANNEL_ID.
+func resolveChatID(override string) string {
+	if override != "" {
+		return override
+	}
+	return os.Getenv("TELEGRAM_CHANNEL_ID")
+}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// stripHTML removes the <b>/<pre> markup formatLogMessage and
+// buildContextualMessage add for Telegram, since Slack and Discord expect plain text (or their own
+// markdown dialects).
+func stripHTML(s string) string {
+	return htmlTagPattern.ReplaceAllString(s, "")
+}
+
+func postJSON(ctx context.Context, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		respBody, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return &telegramAPIError{
+				StatusCode: resp.StatusCode,
+				RetryAfter: parseRetryAfter(respBody),
+				Message:    fmt.Sprintf("webhook error (%s): %s", url, string(respBody)),
+			}
+		}
+		return fmt.Errorf("webhook error (%s): %s", url, string(respBody))
+	}
+	return nil
+}
+
+// slackSink posts to a Slack incoming webhook.
+type slackSink struct {
+	name       string
+	webhookURL string
+}
+
+func (s *slackSink) Name() string { return s.name }
+
+func (s *slackSink) Send(ctx context.Context, event Event) error {
+	return postJSON(ctx, s.webhookURL, map[string]string{"text": stripHTML(event.Message)})
+}
+
+// discordSink posts to a Discord webhook.
+type discordSink struct {
+	name       string
+	webhookURL string
+}
+
+func (s *discordSink) Name() string { return s.name }
+
+func (s *discordSink) Send(ctx context.Context, event Event) error {
+	return postJSON(ctx, s.webhookURL, map[string]string{"content": stripHTML(event.Message)})
+}
+
+// webhookSink POSTs the raw Event as JSON to an arbitrary HTTP endpoint,
+// for integrations that want structured data rather than pre-rendered
+// text.
+type webhookSink struct {
+	name string
+	url  string
+}
+
+func (s *webhookSink) Name() string { return s.name }
+
+func (s *webhookSink) Send(ctx context.Context, event Event) error {
+	return postJSON(ctx, s.url, event)
+}
+
+// unixSocketSink forwards events to a local Unix domain socket, for the
+// pattern where a separate process listens on that socket and relays the
+// message onward (to a desktop notifier, a log aggregator, etc).
+type unixSocketSink struct {
+	name string
+	path string
+}
+
+func (s *unixSocketSink) Name() string { return s.name }
+
+func (s *unixSocketSink) Send(ctx context.Context, event Event) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", s.path)
+	if err != nil {
+		return fmt.Errorf("dialing unix socket %s: %w", s.path, err)
+	}
+	defer conn.Close()
+
+	return json.NewEncoder(conn).Encode(event)
+}
+
+// externalMessage is the payload a unixSocketListener accepts: an
+// external process posts one of these per line and it's forwarded
+// through the normal dispatch pipeline, same as a classified log line.
+type externalMessage struct {
+	Container string   `json:"container"`
+	ChatID    string   `json:"chat_id,omitempty"`
+	Message   string   `json:"message"`
+	Sinks     []string `json:"sinks,omitempty"`
+}
+
+// unixSocketListener is the other half of unixSocketSink's "listener/
+// emitter mode": it listens on a local Unix domain socket so a separate
+// process can POST alerts in, rather than the monitor dialing one out.
+// Each accepted connection is read as newline-delimited JSON
+// externalMessage values and forwarded to dispatch like any other alert.
+type unixSocketListener struct {
+	path     string
+	dispatch *dispatcher
+}
+
+func newUnixSocketListener(path string, dispatch *dispatcher) *unixSocketListener {
+	return &unixSocketListener{path: path, dispatch: dispatch}
+}
+
+// run listens on l.path until ctx is cancelled, accepting connections
+// concurrently. A stale socket file left behind by a previous crashed run
+// is removed first, matching how most Unix socket servers handle restart.
+func (l *unixSocketListener) run(ctx context.Context) error {
+	if err := os.RemoveAll(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing stale unix socket %s: %w", l.path, err)
+	}
+
+	ln, err := net.Listen("unix", l.path)
+	if err != nil {
+		return fmt.Errorf("listening on unix socket %s: %w", l.path, err)
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("accepting on unix socket %s: %w", l.path, err)
+		}
+		go l.handle(conn)
+	}
+}
+
+func (l *unixSocketListener) handle(conn net.Conn) {
+	defer conn.Close()
+
+	dec := json.NewDecoder(conn)
+	for {
+		var msg externalMessage
+		if err := dec.Decode(&msg); err != nil {
+			if err != io.EOF {
+				fmt.Fprintf(os.Stderr, "Error decoding message from unix socket %s: %v\n", l.path, err)
+			}
+			return
+		}
+		if msg.Message == "" {
+			continue
+		}
+
+		sinkNames := msg.Sinks
+		if len(sinkNames) == 0 {
+			sinkNames = []string{defaultSinkName}
+		}
+		l.dispatch.submit(dispatchItem{
+			ContainerName: msg.Container,
+			ChatID:        msg.ChatID,
+			Message:       msg.Message,
+			RawLine:       msg.Message,
+			SinkNames:     sinkNames,
+			At:            time.Now(),
+		})
+	}
+}
+
+// sendWithRetry delivers event via sink, retrying with exponential
+// backoff. A Telegram-style retry_after hint (surfaced through any sink
+// that wraps *telegramAPIError, e.g. a Slack/Discord webhook hitting its
+// own 429) is honored as the wait instead of the default backoff.
+func sendWithRetry(ctx context.Context, sink Sink, event Event) error {
+	const maxAttempts = 3
+	backoff := time.Second
+
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		err := sink.Send(ctx, event)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+
+		if attempt == maxAttempts-1 {
+			break
+		}
+
+		wait := backoff
+		var apiErr *telegramAPIError
+		if errors.As(lastErr, &apiErr) && apiErr.RetryAfter > 0 {
+			wait = apiErr.RetryAfter
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+
+	return fmt.Errorf("sink %q: %w (giving up after %d attempts)", sink.Name(), lastErr, maxAttempts)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketReserve(t *testing.T) {
+	start := time.Unix(0, 0)
+	b := newTokenBucket(2, 1, start) // capacity 2, refills 1 token/sec
+
+	if wait := b.reserve(start); wait != 0 {
+		t.Fatalf("1st reserve: wait = %v, want 0", wait)
+	}
+	if wait := b.reserve(start); wait != 0 {
+		t.Fatalf("2nd reserve: wait = %v, want 0", wait)
+	}
+	// bucket is now empty; a reserve at the same instant must wait a full
+	// token's worth of refill.
+	if wait := b.reserve(start); wait != time.Second {
+		t.Fatalf("3rd reserve: wait = %v, want 1s", wait)
+	}
+	// half a second later, half a token has refilled, but the 3rd reserve
+	// already claimed the next whole token, so this one waits 1.5s.
+	later := start.Add(500 * time.Millisecond)
+	if wait := b.reserve(later); wait != 1500*time.Millisecond {
+		t.Fatalf("reserve after 500ms: wait = %v, want 1.5s", wait)
+	}
+}
+
+func TestTokenBucketRefillCapsAtCapacity(t *testing.T) {
+	start := time.Unix(0, 0)
+	b := newTokenBucket(2, 1, start)
+	b.reserve(start)
+	b.reserve(start)
+
+	// an hour of refill should still cap at capacity, not accumulate
+	// unboundedly.
+	muchLater := start.Add(time.Hour)
+	if wait := b.reserve(muchLater); wait != 0 {
+		t.Fatalf("reserve after long idle: wait = %v, want 0", wait)
+	}
+	if wait := b.reserve(muchLater); wait != 0 {
+		t.Fatalf("second reserve after long idle: wait = %v, want 0", wait)
+	}
+	if wait := b.reserve(muchLater); wait == 0 {
+		t.Fatal("third reserve after long idle: wait = 0, want > 0 (capacity is only 2)")
+	}
+}
+
+// fakeSink is a Sink double whose Send behavior is scripted by a list of
+// errors to return, one per call; calls past the end of the list succeed.
+type fakeSink struct {
+	name    string
+	errs    []error
+	calls   int
+	callsAt []time.Time
+}
+
+func (s *fakeSink) Name() string { return s.name }
+
+func (s *fakeSink) Send(ctx context.Context, event Event) error {
+	s.callsAt = append(s.callsAt, time.Now())
+	defer func() { s.calls++ }()
+	if s.calls < len(s.errs) {
+		return s.errs[s.calls]
+	}
+	return nil
+}
+
+func TestSendWithRetrySucceedsAfterTransientFailures(t *testing.T) {
+	sink := &fakeSink{
+		name: "test",
+		errs: []error{
+			&telegramAPIError{StatusCode: 429, RetryAfter: time.Millisecond},
+			&telegramAPIError{StatusCode: 429, RetryAfter: time.Millisecond},
+		},
+	}
+
+	if err := sendWithRetry(context.Background(), sink, Event{}); err != nil {
+		t.Fatalf("sendWithRetry: %v", err)
+	}
+	if sink.calls != 3 {
+		t.Fatalf("calls = %d, want 3 (2 failures + 1 success)", sink.calls)
+	}
+}
+
+func TestSendWithRetryHonorsRetryAfterOverBackoff(t *testing.T) {
+	sink := &fakeSink{
+		name: "test",
+		errs: []error{
+			&telegramAPIError{StatusCode: 429, RetryAfter: time.Millisecond},
+			&telegramAPIError{StatusCode: 429, RetryAfter: time.Millisecond},
+			&telegramAPIError{StatusCode: 429, RetryAfter: time.Millisecond},
+		},
+	}
+
+	start := time.Now()
+	err := sendWithRetry(context.Background(), sink, Event{})
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("sendWithRetry: want an error after exhausting attempts, got nil")
+	}
+	if sink.calls != 3 {
+		t.Fatalf("calls = %d, want 3 (maxAttempts)", sink.calls)
+	}
+	// the default backoff between 3 attempts is 1s then 2s; retry_after
+	// of 1ms should be used instead, so this must finish in well under 1s.
+	if elapsed > 500*time.Millisecond {
+		t.Fatalf("elapsed = %v, want well under the default 1s/2s backoff (retry_after should win)", elapsed)
+	}
+}
+
+func TestSendWithRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	wantErr := errors.New("boom")
+	sink := &fakeSink{
+		name: "test",
+		errs: []error{
+			&telegramAPIError{StatusCode: 429, RetryAfter: time.Millisecond},
+			&telegramAPIError{StatusCode: 429, RetryAfter: time.Millisecond},
+			wantErr,
+		},
+	}
+
+	err := sendWithRetry(context.Background(), sink, Event{})
+	if err == nil {
+		t.Fatal("sendWithRetry: want an error, got nil")
+	}
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("sendWithRetry error = %v, want it to wrap %v", err, wantErr)
+	}
+	if sink.calls != 3 {
+		t.Fatalf("calls = %d, want 3 (maxAttempts)", sink.calls)
+	}
+}
+
+func TestSendWithRetryStopsOnContextCancellation(t *testing.T) {
+	sink := &fakeSink{
+		name: "test",
+		errs: []error{errors.New("boom"), errors.New("boom"), errors.New("boom")},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := sendWithRetry(ctx, sink, Event{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("sendWithRetry error = %v, want context.DeadlineExceeded", err)
+	}
+	// the plain error above carries no retry_after, so the wait before the
+	// 2nd attempt is the 1s default backoff; the context's 10ms deadline
+	// must win that race, so a 2nd call should never happen.
+	if sink.calls != 1 {
+		t.Fatalf("calls = %d, want 1 (cancelled during the backoff wait)", sink.calls)
+	}
+}
+
+func TestNewSinkDispatchesByType(t *testing.T) {
+	tests := []struct {
+		sinkType string
+		wantType Sink
+	}{
+		{"telegram", &telegramSink{}},
+		{"slack", &slackSink{}},
+		{"discord", &discordSink{}},
+		{"webhook", &webhookSink{}},
+		{"unixsocket", &unixSocketSink{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sinkType, func(t *testing.T) {
+			sink, err := newSink(SinkConfig{Name: "n", Type: tt.sinkType, Target: "t"})
+			if err != nil {
+				t.Fatalf("newSink(%q): %v", tt.sinkType, err)
+			}
+			if got, want := fmt.Sprintf("%T", sink), fmt.Sprintf("%T", tt.wantType); got != want {
+				t.Errorf("newSink(%q) = %s, want %s", tt.sinkType, got, want)
+			}
+			if sink.Name() != "n" {
+				t.Errorf("Name() = %q, want %q", sink.Name(), "n")
+			}
+		})
+	}
+}
+
+func TestNewSinkUnknownTypeErrors(t *testing.T) {
+	if _, err := newSink(SinkConfig{Type: "carrier-pigeon"}); err == nil {
+		t.Fatal("newSink with an unknown type: want an error, got nil")
+	}
+}